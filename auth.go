@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
+)
+
+// Scope grants a single capability, e.g. "container:restart" or
+// "compose:up:web". Target is a glob matched against the container or
+// compose service name the operation applies to; a missing or "*" target
+// grants the scope for any name.
+type Scope struct {
+	Resource string
+	Action   string
+	Target   string
+}
+
+func parseScope(raw string) Scope {
+	parts := strings.SplitN(raw, ":", 3)
+	scope := Scope{Target: "*"}
+	if len(parts) > 0 {
+		scope.Resource = parts[0]
+	}
+	if len(parts) > 1 {
+		scope.Action = parts[1]
+	}
+	if len(parts) > 2 && parts[2] != "" {
+		scope.Target = parts[2]
+	}
+	return scope
+}
+
+func parseScopes(raw []string) []Scope {
+	scopes := make([]Scope, 0, len(raw))
+	for _, r := range raw {
+		scopes = append(scopes, parseScope(r))
+	}
+	return scopes
+}
+
+func (s Scope) allows(resource, action, target string) bool {
+	if s.Resource != resource || s.Action != action {
+		return false
+	}
+	ok, err := path.Match(s.Target, target)
+	return err == nil && ok
+}
+
+// Token is a credential record bound to a fixed set of scopes, carrying a
+// bcrypt-hashed secret for an opaque bearer token looked up by kid. JWTs
+// are not matched against these records: their scopes come from the JWT's
+// own scopes claim.
+type Token struct {
+	KID        string     `json:"kid" yaml:"kid"`
+	SecretHash string     `json:"secret_hash,omitempty" yaml:"secret_hash,omitempty"`
+	Scopes     []string   `json:"scopes" yaml:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty" yaml:"expires_at,omitempty"`
+}
+
+func (t *Token) expired() bool {
+	return t.ExpiresAt != nil && time.Now().After(*t.ExpiresAt)
+}
+
+// AuthContext is attached to the request context once a token has been
+// authenticated, and is consulted by requireScope before an operation
+// runs.
+type AuthContext struct {
+	TokenID string
+	Scopes  []Scope
+}
+
+// Allows reports whether any of the context's scopes grant resource:action
+// against target.
+func (a *AuthContext) Allows(resource, action, target string) bool {
+	for _, scope := range a.Scopes {
+		if scope.allows(resource, action, target) {
+			return true
+		}
+	}
+	return false
+}
+
+type authContextKeyType struct{}
+
+var authContextKey = authContextKeyType{}
+
+func authContextFromRequest(r *http.Request) *AuthContext {
+	authCtx, _ := r.Context().Value(authContextKey).(*AuthContext)
+	return authCtx
+}
+
+// jwtClaims is the shape of the scopes claim expected on an externally
+// minted JWT; RegisteredClaims gives us exp/nbf/sub handling for free.
+type jwtClaims struct {
+	Scopes []string `json:"scopes"`
+	jwt.RegisteredClaims
+}
+
+// TokenStore is the source of truth for which callers may perform which
+// operations. Tokens are loaded once at startup from a YAML/JSON file;
+// JWT verification keys are configured separately so a token file isn't
+// required to accept externally minted JWTs.
+type TokenStore struct {
+	tokens  map[string]*Token
+	hmacKey []byte
+	rsaKey  *rsa.PublicKey
+}
+
+// loadTokenStore builds a TokenStore from tokensPath (a YAML or JSON file
+// of Token records) and/or the given JWT verification key material. It
+// returns (nil, nil) when none of the three are configured, signalling
+// that authentication is disabled entirely.
+func loadTokenStore(tokensPath, jwtHMACSecret, jwtRSAPublicKeyPath string) (*TokenStore, error) {
+	if tokensPath == "" && jwtHMACSecret == "" && jwtRSAPublicKeyPath == "" {
+		return nil, nil
+	}
+
+	store := &TokenStore{tokens: map[string]*Token{}}
+
+	if jwtHMACSecret != "" {
+		store.hmacKey = []byte(jwtHMACSecret)
+	}
+
+	if jwtRSAPublicKeyPath != "" {
+		keyBytes, err := os.ReadFile(jwtRSAPublicKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JWT RSA public key %s: %w", jwtRSAPublicKeyPath, err)
+		}
+		block, _ := pem.Decode(keyBytes)
+		if block == nil {
+			return nil, fmt.Errorf("no PEM block found in JWT RSA public key %s", jwtRSAPublicKeyPath)
+		}
+		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse JWT RSA public key %s: %w", jwtRSAPublicKeyPath, err)
+		}
+		rsaPub, ok := pub.(*rsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("JWT RSA public key %s is not an RSA key", jwtRSAPublicKeyPath)
+		}
+		store.rsaKey = rsaPub
+	}
+
+	if tokensPath == "" {
+		return store, nil
+	}
+
+	data, err := os.ReadFile(tokensPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token file %s: %w", tokensPath, err)
+	}
+
+	var tokens []*Token
+	if err := yaml.Unmarshal(data, &tokens); err != nil {
+		return nil, fmt.Errorf("failed to parse token file %s: %w", tokensPath, err)
+	}
+
+	for _, t := range tokens {
+		if t.KID == "" {
+			return nil, fmt.Errorf("token in %s is missing a kid", tokensPath)
+		}
+		store.tokens[t.KID] = t
+	}
+
+	return store, nil
+}
+
+// Authenticate validates the request's Authorization header, either as an
+// opaque bearer token looked up by the X-Key-Id header or as a
+// self-contained JWT, and returns the resulting AuthContext.
+func (s *TokenStore) Authenticate(r *http.Request) (*AuthContext, error) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return nil, Unauthorized(fmt.Errorf("missing authorization token"))
+	}
+
+	raw, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok {
+		return nil, Unauthorized(fmt.Errorf("authorization header must use the Bearer scheme"))
+	}
+
+	if strings.Count(raw, ".") == 2 {
+		return s.authenticateJWT(raw)
+	}
+
+	return s.authenticateOpaque(r, raw)
+}
+
+func (s *TokenStore) authenticateOpaque(r *http.Request, secret string) (*AuthContext, error) {
+	kid := r.Header.Get("X-Key-Id")
+	if kid == "" {
+		return nil, Unauthorized(fmt.Errorf("missing X-Key-Id header"))
+	}
+
+	token, ok := s.tokens[kid]
+	if !ok {
+		return nil, Unauthorized(fmt.Errorf("unknown key id"))
+	}
+
+	if token.expired() {
+		return nil, Unauthorized(fmt.Errorf("token has expired"))
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(token.SecretHash), []byte(secret)); err != nil {
+		return nil, Unauthorized(fmt.Errorf("invalid token"))
+	}
+
+	return &AuthContext{TokenID: kid, Scopes: parseScopes(token.Scopes)}, nil
+}
+
+func (s *TokenStore) authenticateJWT(raw string) (*AuthContext, error) {
+	claims := &jwtClaims{}
+	parsed, err := jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (interface{}, error) {
+		switch t.Method.(type) {
+		case *jwt.SigningMethodHMAC:
+			if s.hmacKey == nil {
+				return nil, fmt.Errorf("HMAC-signed tokens are not accepted")
+			}
+			return s.hmacKey, nil
+		case *jwt.SigningMethodRSA:
+			if s.rsaKey == nil {
+				return nil, fmt.Errorf("RSA-signed tokens are not accepted")
+			}
+			return s.rsaKey, nil
+		default:
+			return nil, fmt.Errorf("unsupported JWT signing method: %v", t.Header["alg"])
+		}
+	})
+	if err != nil || !parsed.Valid {
+		return nil, Unauthorized(fmt.Errorf("invalid token: %w", err))
+	}
+
+	return &AuthContext{TokenID: claims.Subject, Scopes: parseScopes(claims.Scopes)}, nil
+}
+
+// authMiddleware authenticates the request against store, attaching the
+// resulting AuthContext to the request context for handlers to consult
+// via requireScope. A nil store means authentication is disabled.
+func authMiddleware(next http.HandlerFunc, store *TokenStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if store == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		authCtx, err := store.Authenticate(r)
+		if err != nil {
+			respondWithError(w, err, r)
+			logger.WithField("remote_addr", r.RemoteAddr).Warnf("authentication failed: %v", err)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), authContextKey, authCtx)))
+	}
+}
+
+// requireScope checks that the authenticated caller's token grants
+// resource:action against target, audit-logging the decision either way,
+// and writes a 403 response if it doesn't. It returns whether the
+// operation is allowed to proceed.
+func requireScope(w http.ResponseWriter, r *http.Request, resource, action, target string) bool {
+	authCtx := authContextFromRequest(r)
+	if authCtx == nil {
+		// No token store is configured, so authorisation is disabled.
+		return true
+	}
+
+	allowed := authCtx.Allows(resource, action, target)
+
+	fields := logrus.Fields{
+		"token_id":    authCtx.TokenID,
+		"remote_addr": r.RemoteAddr,
+		"resource":    resource,
+		"action":      action,
+		"target":      target,
+	}
+
+	if !allowed {
+		logger.WithFields(fields).Warn("operation denied: missing scope")
+		respondWithError(w, Forbidden(fmt.Errorf("token is not scoped for %s:%s on %s", resource, action, target)), r)
+		return false
+	}
+
+	logger.WithFields(fields).Info("operation authorised")
+	return true
+}
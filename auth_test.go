@@ -0,0 +1,82 @@
+package main
+
+import "testing"
+
+func TestScopeAllows(t *testing.T) {
+	tests := []struct {
+		name     string
+		scope    Scope
+		resource string
+		action   string
+		target   string
+		want     bool
+	}{
+		{
+			name:     "exact match",
+			scope:    Scope{Resource: "container", Action: "restart", Target: "myapp"},
+			resource: "container", action: "restart", target: "myapp",
+			want: true,
+		},
+		{
+			name:     "wildcard target",
+			scope:    Scope{Resource: "container", Action: "restart", Target: "*"},
+			resource: "container", action: "restart", target: "anything",
+			want: true,
+		},
+		{
+			name:     "glob prefix matches",
+			scope:    Scope{Resource: "compose", Action: "up", Target: "web-*"},
+			resource: "compose", action: "up", target: "web-1",
+			want: true,
+		},
+		{
+			name:     "glob prefix does not match a different prefix",
+			scope:    Scope{Resource: "compose", Action: "up", Target: "web-*"},
+			resource: "compose", action: "up", target: "db-1",
+			want: false,
+		},
+		{
+			name:     "wrong resource is denied",
+			scope:    Scope{Resource: "container", Action: "restart", Target: "*"},
+			resource: "image", action: "restart", target: "myapp",
+			want: false,
+		},
+		{
+			name:     "wrong action is denied",
+			scope:    Scope{Resource: "container", Action: "restart", Target: "*"},
+			resource: "container", action: "stop", target: "myapp",
+			want: false,
+		},
+		{
+			name:     "glob target does not fall back to substring matching",
+			scope:    Scope{Resource: "container", Action: "logs", Target: "my-container"},
+			resource: "container", action: "logs", target: "my-container-2",
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.scope.allows(tt.resource, tt.action, tt.target); got != tt.want {
+				t.Errorf("allows(%q, %q, %q) = %v, want %v", tt.resource, tt.action, tt.target, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseScope(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want Scope
+	}{
+		{"container:restart:myapp", Scope{Resource: "container", Action: "restart", Target: "myapp"}},
+		{"container:restart", Scope{Resource: "container", Action: "restart", Target: "*"}},
+		{"events:read:*", Scope{Resource: "events", Action: "read", Target: "*"}},
+	}
+
+	for _, tt := range tests {
+		if got := parseScope(tt.raw); got != tt.want {
+			t.Errorf("parseScope(%q) = %+v, want %+v", tt.raw, got, tt.want)
+		}
+	}
+}
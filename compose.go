@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/compose-spec/compose-go/v2/cli"
+	"github.com/compose-spec/compose-go/v2/types"
+	composeapi "github.com/docker/compose/v2/pkg/api"
+	composecmd "github.com/docker/compose/v2/pkg/compose"
+	"github.com/docker/compose/v2/pkg/progress"
+	"github.com/docker/docker/client"
+)
+
+// ComposeService drives Docker Compose projects in-process via
+// compose-go and docker/compose's own Service backend, so this binary no
+// longer needs the `docker` CLI (or its compose plugin) to be installed
+// alongside it.
+type ComposeService struct {
+	backend composeapi.Service
+}
+
+// newComposeService builds a ComposeService backed by dockerCli's Docker
+// API connection.
+func newComposeService(dockerCli *client.Client) *ComposeService {
+	return &ComposeService{backend: composecmd.NewComposeService(dockerCli)}
+}
+
+// loadProject parses the default compose file under projectPath (the first
+// of compose.yaml, compose.yml, docker-compose.yml, docker-compose.yaml),
+// its matching override file, and .env. Other docker*compose*.y*ml files
+// in projectPath are not loaded. The path is always resolved to an
+// absolute path up front,
+// rather than chdir'ing the process into it, so concurrent requests
+// against different projects never race over shared process state.
+func (c *ComposeService) loadProject(ctx context.Context, projectPath string) (*types.Project, error) {
+	absPath, err := filepath.Abs(projectPath)
+	if err != nil {
+		return nil, InvalidParameter(fmt.Errorf("failed to resolve project path %s: %w", projectPath, err))
+	}
+
+	options, err := cli.NewProjectOptions(
+		nil,
+		cli.WithWorkingDirectory(absPath),
+		cli.WithDefaultConfigPath,
+		cli.WithDotEnv,
+		cli.WithOsEnv,
+	)
+	if err != nil {
+		return nil, InvalidParameter(fmt.Errorf("failed to configure compose project at %s: %w", absPath, err))
+	}
+
+	project, err := options.LoadProject(ctx)
+	if err != nil {
+		return nil, InvalidParameter(fmt.Errorf("failed to load compose project at %s: %w", absPath, err))
+	}
+
+	return project, nil
+}
+
+// Run executes operation (one of up/down/pull/restart/stop/start) against
+// the compose project at projectPath, optionally scoped to a single
+// service and/or profile. Build and pull progress is streamed to w for
+// the duration of the call; ctx cancellation (e.g. a client disconnect)
+// tears down the operation.
+func (c *ComposeService) Run(ctx context.Context, projectPath, operation, service, profile string, w progress.Writer) error {
+	project, err := c.loadProject(ctx, projectPath)
+	if err != nil {
+		return err
+	}
+
+	if profile != "" {
+		project, err = project.WithProfiles([]string{profile})
+		if err != nil {
+			return InvalidParameter(fmt.Errorf("failed to apply compose profile %s: %w", profile, err))
+		}
+	}
+
+	var services []string
+	if service != "" {
+		services = []string{service}
+	}
+
+	ctx = progress.WithContextWriter(ctx, w)
+
+	switch operation {
+	case "up":
+		return c.backend.Up(ctx, project, composeapi.UpOptions{
+			Create: composeapi.CreateOptions{Services: services},
+			Start:  composeapi.StartOptions{Project: project, Services: services},
+		})
+	case "down":
+		return c.backend.Down(ctx, project.Name, composeapi.DownOptions{Project: project, Services: services})
+	case "pull":
+		return c.backend.Pull(ctx, project, composeapi.PullOptions{Services: services})
+	case "restart":
+		return c.backend.Restart(ctx, project.Name, composeapi.RestartOptions{Project: project, Services: services})
+	case "stop":
+		return c.backend.Stop(ctx, project.Name, composeapi.StopOptions{Project: project, Services: services})
+	case "start":
+		return c.backend.Start(ctx, project.Name, composeapi.StartOptions{Project: project, Services: services})
+	default:
+		return InvalidParameter(fmt.Errorf("invalid compose operation: %s", operation))
+	}
+}
+
+// ndjsonProgressWriter adapts docker/compose's progress.Writer interface
+// to the NDJSON wire format used by the HTTP handler, flushing after each
+// event so clients see build/pull progress as it happens rather than
+// buffered at the end.
+type ndjsonProgressWriter struct {
+	encode func(v interface{})
+	flush  func()
+}
+
+func (w *ndjsonProgressWriter) Event(e progress.Event) {
+	w.encode(e)
+	w.flush()
+}
+
+func (w *ndjsonProgressWriter) Events(events []progress.Event) {
+	for _, e := range events {
+		w.Event(e)
+	}
+}
+
+func (w *ndjsonProgressWriter) TailMsgf(format string, args ...interface{}) {
+	w.encode(map[string]string{"message": fmt.Sprintf(format, args...)})
+	w.flush()
+}
+
+// Start satisfies progress.Writer; this writer has no terminal session to
+// set up, so there's nothing to do beyond letting the operation proceed.
+func (w *ndjsonProgressWriter) Start(ctx context.Context) error {
+	return nil
+}
+
+// Stop satisfies progress.Writer; events are flushed as they're emitted,
+// so there's no buffered state to flush on stop.
+func (w *ndjsonProgressWriter) Stop() {}
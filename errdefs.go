@@ -0,0 +1,234 @@
+package main
+
+import (
+	"net/http"
+
+	dockererrdefs "github.com/docker/docker/errdefs"
+)
+
+// This file implements a small errdefs-style error taxonomy, modelled on
+// Docker's own github.com/docker/docker/errdefs package. Handlers return
+// one of these typed errors instead of hardcoding an HTTP status code at
+// every call site; httpStatusFromError does the status mapping in one
+// place.
+
+// ErrNotFound signals that the requested resource does not exist.
+type ErrNotFound interface {
+	NotFound()
+}
+
+// ErrInvalidParameter signals that the request was malformed.
+type ErrInvalidParameter interface {
+	InvalidParameter()
+}
+
+// ErrConflict signals that the request conflicts with the current state
+// of the resource.
+type ErrConflict interface {
+	Conflict()
+}
+
+// ErrForbidden signals that the caller is not permitted to perform the
+// operation, e.g. its token lacks the required scope.
+type ErrForbidden interface {
+	Forbidden()
+}
+
+// ErrUnauthorized signals a missing or invalid credential.
+type ErrUnauthorized interface {
+	Unauthorized()
+}
+
+// ErrUnavailable signals that a dependency (e.g. the Docker daemon) is
+// temporarily unreachable.
+type ErrUnavailable interface {
+	Unavailable()
+}
+
+// ErrSystem signals an unclassified internal error.
+type ErrSystem interface {
+	System()
+}
+
+type wrappedError struct {
+	error
+}
+
+func (w wrappedError) Unwrap() error {
+	return w.error
+}
+
+type errNotFound struct{ wrappedError }
+
+func (errNotFound) NotFound() {}
+
+// NotFound wraps err so that it satisfies ErrNotFound.
+func NotFound(err error) error {
+	if err == nil {
+		return nil
+	}
+	return errNotFound{wrappedError{err}}
+}
+
+type errInvalidParameter struct{ wrappedError }
+
+func (errInvalidParameter) InvalidParameter() {}
+
+// InvalidParameter wraps err so that it satisfies ErrInvalidParameter.
+func InvalidParameter(err error) error {
+	if err == nil {
+		return nil
+	}
+	return errInvalidParameter{wrappedError{err}}
+}
+
+type errConflict struct{ wrappedError }
+
+func (errConflict) Conflict() {}
+
+// Conflict wraps err so that it satisfies ErrConflict.
+func Conflict(err error) error {
+	if err == nil {
+		return nil
+	}
+	return errConflict{wrappedError{err}}
+}
+
+type errForbidden struct{ wrappedError }
+
+func (errForbidden) Forbidden() {}
+
+// Forbidden wraps err so that it satisfies ErrForbidden.
+func Forbidden(err error) error {
+	if err == nil {
+		return nil
+	}
+	return errForbidden{wrappedError{err}}
+}
+
+type errUnauthorized struct{ wrappedError }
+
+func (errUnauthorized) Unauthorized() {}
+
+// Unauthorized wraps err so that it satisfies ErrUnauthorized.
+func Unauthorized(err error) error {
+	if err == nil {
+		return nil
+	}
+	return errUnauthorized{wrappedError{err}}
+}
+
+type errUnavailable struct{ wrappedError }
+
+func (errUnavailable) Unavailable() {}
+
+// Unavailable wraps err so that it satisfies ErrUnavailable.
+func Unavailable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return errUnavailable{wrappedError{err}}
+}
+
+type errSystem struct{ wrappedError }
+
+func (errSystem) System() {}
+
+// System wraps err so that it satisfies ErrSystem.
+func System(err error) error {
+	if err == nil {
+		return nil
+	}
+	return errSystem{wrappedError{err}}
+}
+
+// matchesCategory walks err's Unwrap chain looking for an error that
+// implements the given marker interface.
+func matchesCategory(err error, match func(error) bool) bool {
+	for err != nil {
+		if match(err) {
+			return true
+		}
+		unwrapper, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			return false
+		}
+		err = unwrapper.Unwrap()
+	}
+	return false
+}
+
+func isNotFound(err error) bool {
+	return matchesCategory(err, func(e error) bool { _, ok := e.(ErrNotFound); return ok })
+}
+
+func isInvalidParameter(err error) bool {
+	return matchesCategory(err, func(e error) bool { _, ok := e.(ErrInvalidParameter); return ok })
+}
+
+func isConflict(err error) bool {
+	return matchesCategory(err, func(e error) bool { _, ok := e.(ErrConflict); return ok })
+}
+
+func isForbidden(err error) bool {
+	return matchesCategory(err, func(e error) bool { _, ok := e.(ErrForbidden); return ok })
+}
+
+func isUnauthorized(err error) bool {
+	return matchesCategory(err, func(e error) bool { _, ok := e.(ErrUnauthorized); return ok })
+}
+
+func isUnavailable(err error) bool {
+	return matchesCategory(err, func(e error) bool { _, ok := e.(ErrUnavailable); return ok })
+}
+
+// httpStatusFromError maps a (possibly wrapped) typed error to the HTTP
+// status code it should produce. Unclassified errors map to 500, matching
+// the historical behaviour of this package.
+func httpStatusFromError(err error) int {
+	switch {
+	case err == nil:
+		return http.StatusOK
+	case isNotFound(err):
+		return http.StatusNotFound
+	case isInvalidParameter(err):
+		return http.StatusBadRequest
+	case isConflict(err):
+		return http.StatusConflict
+	case isForbidden(err):
+		return http.StatusForbidden
+	case isUnauthorized(err):
+		return http.StatusUnauthorized
+	case isUnavailable(err):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// classifyDockerError wraps an error returned by the Docker client in the
+// appropriate typed error, so that handlers don't need to special-case
+// Docker API errors themselves. It defers to the Docker daemon's own
+// errdefs classification (the same categories this file's ErrXxx
+// interfaces mirror) rather than only recognising "not found".
+func classifyDockerError(err error) error {
+	if err == nil {
+		return nil
+	}
+	switch {
+	case dockererrdefs.IsNotFound(err):
+		return NotFound(err)
+	case dockererrdefs.IsInvalidParameter(err):
+		return InvalidParameter(err)
+	case dockererrdefs.IsConflict(err):
+		return Conflict(err)
+	case dockererrdefs.IsForbidden(err):
+		return Forbidden(err)
+	case dockererrdefs.IsUnauthorized(err):
+		return Unauthorized(err)
+	case dockererrdefs.IsUnavailable(err):
+		return Unavailable(err)
+	default:
+		return System(err)
+	}
+}
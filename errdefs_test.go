@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	dockererrdefs "github.com/docker/docker/errdefs"
+)
+
+func TestClassifyDockerErrorCategories(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		is   func(error) bool
+	}{
+		{"not found", dockererrdefs.NotFound(errors.New("no such container")), isNotFound},
+		{"conflict", dockererrdefs.Conflict(errors.New("name already in use")), isConflict},
+		{"invalid parameter", dockererrdefs.InvalidParameter(errors.New("bad request")), isInvalidParameter},
+		{"forbidden", dockererrdefs.Forbidden(errors.New("not permitted")), isForbidden},
+		{"unauthorized", dockererrdefs.Unauthorized(errors.New("no credentials")), isUnauthorized},
+		{"unavailable", dockererrdefs.Unavailable(errors.New("daemon unreachable")), isUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			classified := classifyDockerError(tt.err)
+			if !tt.is(classified) {
+				t.Errorf("classifyDockerError(%v) was not classified as %s", tt.err, tt.name)
+			}
+		})
+	}
+}
+
+func TestClassifyDockerErrorDefaultsToSystem(t *testing.T) {
+	err := classifyDockerError(errors.New("some unclassified docker error"))
+	if status := httpStatusFromError(err); status != http.StatusInternalServerError {
+		t.Errorf("expected an unclassified error to map to 500, got %d", status)
+	}
+}
+
+func TestClassifyDockerErrorNil(t *testing.T) {
+	if err := classifyDockerError(nil); err != nil {
+		t.Errorf("expected classifyDockerError(nil) to return nil, got %v", err)
+	}
+}
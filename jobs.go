@@ -0,0 +1,420 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/image"
+)
+
+// JobStatus is the lifecycle state of an asynchronous operation.
+type JobStatus string
+
+const (
+	JobStatusQueued    JobStatus = "queued"
+	JobStatusRunning   JobStatus = "running"
+	JobStatusSucceeded JobStatus = "succeeded"
+	JobStatusFailed    JobStatus = "failed"
+	JobStatusCancelled JobStatus = "cancelled"
+)
+
+// jobLogRetention bounds the in-memory ring buffer kept per job so a
+// long-running `compose up` can't grow without limit.
+const jobLogRetention = 1000
+
+// Job tracks a single asynchronous operation (image pull, compose
+// up/pull) from submission through completion, along with a ring buffer
+// of its accumulated output for /jobs/{id}/logs.
+type Job struct {
+	ID        string
+	Operation string
+	Target    string
+	CreatedAt time.Time
+
+	mu        sync.Mutex
+	status    JobStatus
+	errMsg    string
+	updatedAt time.Time
+	cancel    context.CancelFunc
+	log       []string
+	subs      map[chan string]struct{}
+}
+
+func newJob(id, operation, target string) *Job {
+	now := time.Now()
+	return &Job{
+		ID:        id,
+		Operation: operation,
+		Target:    target,
+		CreatedAt: now,
+		status:    JobStatusQueued,
+		updatedAt: now,
+		subs:      map[chan string]struct{}{},
+	}
+}
+
+func (j *Job) setCancel(cancel context.CancelFunc) {
+	j.mu.Lock()
+	j.cancel = cancel
+	j.mu.Unlock()
+}
+
+// requestCancel tears down the job's context, if it is still running.
+func (j *Job) requestCancel() {
+	j.mu.Lock()
+	cancel := j.cancel
+	j.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
+func (j *Job) setStatus(status JobStatus, err error) {
+	j.mu.Lock()
+	j.status = status
+	if err != nil {
+		j.errMsg = err.Error()
+	}
+	j.updatedAt = time.Now()
+	j.mu.Unlock()
+}
+
+// appendLog records a line of job output, fanning it out to any open
+// /jobs/{id}/logs subscribers.
+func (j *Job) appendLog(line string) {
+	j.mu.Lock()
+	j.log = append(j.log, line)
+	if len(j.log) > jobLogRetention {
+		j.log = j.log[len(j.log)-jobLogRetention:]
+	}
+	for ch := range j.subs {
+		select {
+		case ch <- line:
+		default:
+		}
+	}
+	j.mu.Unlock()
+}
+
+// subscribe returns a channel that receives new log lines as they're
+// appended, and an unsubscribe func that must be called when the caller
+// is done reading.
+func (j *Job) subscribe() (<-chan string, func()) {
+	ch := make(chan string, 64)
+	j.mu.Lock()
+	j.subs[ch] = struct{}{}
+	j.mu.Unlock()
+
+	return ch, func() {
+		j.mu.Lock()
+		if _, ok := j.subs[ch]; ok {
+			delete(j.subs, ch)
+			close(ch)
+		}
+		j.mu.Unlock()
+	}
+}
+
+func (j *Job) logTail(n int) []string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if len(j.log) <= n {
+		return append([]string(nil), j.log...)
+	}
+	return append([]string(nil), j.log[len(j.log)-n:]...)
+}
+
+func (j *Job) isDone() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.status != JobStatusQueued && j.status != JobStatusRunning
+}
+
+func (j *Job) snapshot() map[string]interface{} {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	result := map[string]interface{}{
+		"id":         j.ID,
+		"operation":  j.Operation,
+		"target":     j.Target,
+		"status":     j.status,
+		"created_at": j.CreatedAt,
+		"updated_at": j.updatedAt,
+		"log_tail":   j.log[max(0, len(j.log)-20):],
+	}
+	if j.errMsg != "" {
+		result["error"] = j.errMsg
+	}
+	return result
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// JobStore keeps job state in memory, keyed by an id derived from the
+// client's Idempotency-Key header (so a retried request collapses onto
+// the job it already created rather than starting a second one).
+// Completed jobs are swept once they're older than retention.
+type JobStore struct {
+	mu        sync.Mutex
+	jobs      map[string]*Job
+	retention time.Duration
+}
+
+func newJobStore(retention time.Duration) *JobStore {
+	return &JobStore{jobs: map[string]*Job{}, retention: retention}
+}
+
+// getOrCreate returns the existing job for id, or creates one, reporting
+// whether it created a new job so the caller knows whether to launch the
+// underlying operation.
+func (s *JobStore) getOrCreate(id, operation, target string) (*Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if job, ok := s.jobs[id]; ok {
+		return job, false
+	}
+
+	job := newJob(id, operation, target)
+	s.jobs[id] = job
+	return job, true
+}
+
+func (s *JobStore) get(id string) (*Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	return job, ok
+}
+
+func (s *JobStore) sweep() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cutoff := time.Now().Add(-s.retention)
+	for id, job := range s.jobs {
+		job.mu.Lock()
+		expired := job.status != JobStatusQueued && job.status != JobStatusRunning && job.updatedAt.Before(cutoff)
+		job.mu.Unlock()
+		if expired {
+			delete(s.jobs, id)
+		}
+	}
+}
+
+// startSweeper periodically evicts completed jobs last updated more than
+// s.retention ago until ctx is cancelled.
+func (s *JobStore) startSweeper(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.sweep()
+			}
+		}
+	}()
+}
+
+// requireJobScope checks that the caller's token is scoped for the job's
+// own operation and target, e.g. "compose:up" against job.Target - not
+// whatever operation/target the current request happens to ask for. This
+// matters because an async request can resolve to a pre-existing job (an
+// Idempotency-Key collision, or simply a guessed job id) whose real
+// operation/target may belong to a different caller entirely.
+func requireJobScope(w http.ResponseWriter, r *http.Request, job *Job) bool {
+	resource, action, _ := strings.Cut(job.Operation, ":")
+	return requireScope(w, r, resource, action, job.Target)
+}
+
+// isAsyncRequest reports whether r asked to be handled asynchronously,
+// either via the standard `Prefer: respond-async` header or `?async=true`.
+func isAsyncRequest(r *http.Request) bool {
+	if strings.EqualFold(r.Header.Get("Prefer"), "respond-async") {
+		return true
+	}
+	async, _ := strconv.ParseBool(r.URL.Query().Get("async"))
+	return async
+}
+
+// jobIDFromRequest derives a stable job id from the Idempotency-Key
+// header, if present, so retried requests collapse onto the same job
+// instead of re-running the operation. Without one, a random id is used.
+func jobIDFromRequest(r *http.Request) string {
+	if key := r.Header.Get("Idempotency-Key"); key != "" {
+		sum := sha256.Sum256([]byte(key))
+		return hex.EncodeToString(sum[:16])
+	}
+	return randomID()
+}
+
+func randomID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return hex.EncodeToString([]byte(fmt.Sprintf("%d", time.Now().UnixNano())))
+	}
+	return hex.EncodeToString(buf)
+}
+
+// runImagePullJob drives an `image pull` to completion in the
+// background, recording each line of Docker's pull progress to the job's
+// log so a client can reconnect via /jobs/{id}/logs.
+func runImagePullJob(ctx context.Context, job *Job, imageName, registryAuth string) {
+	job.setStatus(JobStatusRunning, nil)
+
+	reader, err := dockerClient.ImagePull(ctx, imageName, image.PullOptions{RegistryAuth: registryAuth})
+	if err != nil {
+		job.appendLog(err.Error())
+		job.setStatus(JobStatusFailed, classifyDockerError(err))
+		return
+	}
+	defer reader.Close()
+
+	decoder := json.NewDecoder(reader)
+	for {
+		var msg map[string]interface{}
+		if err := decoder.Decode(&msg); err != nil {
+			break
+		}
+		status, _ := msg["status"].(string)
+		if status == "" {
+			continue
+		}
+		if id, ok := msg["id"].(string); ok && id != "" {
+			job.appendLog(fmt.Sprintf("%s: %s", status, id))
+		} else {
+			job.appendLog(status)
+		}
+	}
+
+	if ctx.Err() != nil {
+		job.setStatus(JobStatusCancelled, ctx.Err())
+		return
+	}
+
+	job.setStatus(JobStatusSucceeded, nil)
+}
+
+// runComposeJob drives a Compose operation to completion in the
+// background, recording its progress events to the job's log.
+func runComposeJob(ctx context.Context, job *Job, projectPath, operation, service, profile string) {
+	job.setStatus(JobStatusRunning, nil)
+
+	progressWriter := &ndjsonProgressWriter{
+		encode: func(v interface{}) {
+			payload, err := json.Marshal(v)
+			if err != nil {
+				return
+			}
+			job.appendLog(string(payload))
+		},
+		flush: func() {},
+	}
+
+	err := composeSvc.Run(ctx, projectPath, operation, service, profile, progressWriter)
+	if ctx.Err() != nil {
+		job.setStatus(JobStatusCancelled, ctx.Err())
+		return
+	}
+	if err != nil {
+		job.appendLog(err.Error())
+		job.setStatus(JobStatusFailed, err)
+		return
+	}
+
+	job.setStatus(JobStatusSucceeded, nil)
+}
+
+// handleJobs serves GET/DELETE on /jobs/{id} and GET on /jobs/{id}/logs.
+func handleJobs(w http.ResponseWriter, r *http.Request, config *Config) {
+	rest := strings.TrimPrefix(r.URL.Path, "/jobs/")
+	parts := strings.SplitN(rest, "/", 2)
+	id := parts[0]
+	if id == "" {
+		respondWithError(w, InvalidParameter(fmt.Errorf("job id is required")), r)
+		return
+	}
+
+	job, ok := jobStore.get(id)
+	if !ok {
+		respondWithError(w, NotFound(fmt.Errorf("job %s not found", id)), r)
+		return
+	}
+
+	if !requireJobScope(w, r, job) {
+		return
+	}
+
+	if len(parts) == 2 && parts[1] == "logs" {
+		handleJobLogs(w, r, job)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		respondWithJSON(w, http.StatusOK, job.snapshot())
+	case http.MethodDelete:
+		job.requestCancel()
+		respondWithMessage(w, http.StatusOK, fmt.Sprintf("Cancellation requested for job %s", id), r)
+	default:
+		respondWithError(w, InvalidParameter(fmt.Errorf("unsupported method %s for /jobs/{id}", r.Method)), r)
+	}
+}
+
+// handleJobLogs streams a job's accumulated output, followed by any new
+// lines appended while the client is connected, as SSE.
+func handleJobLogs(w http.ResponseWriter, r *http.Request, job *Job) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher, canFlush := w.(http.Flusher)
+
+	ch, unsubscribe := job.subscribe()
+	defer unsubscribe()
+
+	for _, line := range job.logTail(jobLogRetention) {
+		fmt.Fprintf(w, "data: %s\n\n", line)
+	}
+	if canFlush {
+		flusher.Flush()
+	}
+
+	if job.isDone() {
+		return
+	}
+
+	ctx := r.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case line, ok := <-ch:
+			if !ok {
+				return
+			}
+			fmt.Fprintf(w, "data: %s\n\n", line)
+			if canFlush {
+				flusher.Flush()
+			}
+		}
+	}
+}
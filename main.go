@@ -2,17 +2,15 @@ package main
 
 import (
 	"context"
-	"crypto/rand"
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
-	"os/exec"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/spf13/pflag"
 
@@ -25,32 +23,27 @@ import (
 )
 
 var (
-	logger       *logrus.Logger
-	dockerClient *client.Client
+	logger            *logrus.Logger
+	dockerClient      *client.Client
+	composeSvc        *ComposeService
+	jobStore          *JobStore
+	registryCredStore *registryCredentialStore
 )
 
 // Config holds the application configuration
 type Config struct {
-	AuthToken          string
-	AllowRestart       bool
-	AllowStop          bool
-	AllowStart         bool
-	AllowRemove        bool
-	AllowPull          bool
-	AllowComposeOps    bool
-	Port               int
-	LogLevel           string
-	ComposeProjectPath string
-}
-
-// AppError represents an application-specific error
-type AppError struct {
-	Message string
-	Code    int
-}
-
-func (e *AppError) Error() string {
-	return e.Message
+	TokensFile          string
+	JWTHMACSecret       string
+	JWTRSAPublicKeyPath string
+	Port                int
+	LogLevel            string
+	ComposeProjectPath  string
+	MetricsListen       string
+	MetricsToken        string
+	MetricsAllowFrom    []string
+	JobRetention        time.Duration
+	RegistryCredentials string
+	DockerConfigPath    string
 }
 
 var Version string // Version is set by the build system
@@ -78,15 +71,48 @@ func main() {
 		logger.Fatalf("Failed to create Docker client: %v", err)
 	}
 
+	composeSvc = newComposeService(dockerClient)
+
+	jobStore = newJobStore(config.JobRetention)
+	jobStore.startSweeper(context.Background())
+
+	tokenStore, err := loadTokenStore(config.TokensFile, config.JWTHMACSecret, config.JWTRSAPublicKeyPath)
+	if err != nil {
+		logger.Fatalf("Failed to load token store: %v", err)
+	}
+	if tokenStore == nil {
+		logger.Warn("No tokens file or JWT verification key configured - all requests will be accepted without authentication")
+	}
+
+	registryCredStore, err = loadRegistryCredentialStore(config.RegistryCredentials)
+	if err != nil {
+		logger.Fatalf("Failed to load registry credentials file: %v", err)
+	}
+
 	http.HandleFunc("/container", authMiddleware(func(w http.ResponseWriter, r *http.Request) {
 		handleContainerOperation(w, r, config)
-	}, config))
+	}, tokenStore))
 	http.HandleFunc("/image", authMiddleware(func(w http.ResponseWriter, r *http.Request) {
 		handleImageOperation(w, r, config)
-	}, config))
+	}, tokenStore))
 	http.HandleFunc("/compose", authMiddleware(func(w http.ResponseWriter, r *http.Request) {
 		handleComposeOperation(w, r, config)
-	}, config))
+	}, tokenStore))
+	http.HandleFunc("/container/logs", authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		handleContainerLogs(w, r, config)
+	}, tokenStore))
+	http.HandleFunc("/events", authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		handleEvents(w, r, config)
+	}, tokenStore))
+	http.HandleFunc("/container/stats", authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		handleContainerStats(w, r, config)
+	}, tokenStore))
+	http.HandleFunc("/jobs/", authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		handleJobs(w, r, config)
+	}, tokenStore))
+
+	go startMetricsCollector(context.Background())
+	startMetricsServer(config)
 
 	addr := fmt.Sprintf(":%d", config.Port)
 	logger.Infof("Starting DockerAPI on %s", addr)
@@ -102,12 +128,12 @@ func handleContainerOperation(w http.ResponseWriter, r *http.Request, config *Co
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondWithError(w, &AppError{Message: "Invalid request body", Code: http.StatusBadRequest}, r)
+		respondWithError(w, InvalidParameter(fmt.Errorf("invalid request body: %w", err)), r)
 		return
 	}
 
 	if req.Container == "" {
-		respondWithError(w, &AppError{Message: "Container name is required", Code: http.StatusBadRequest}, r)
+		respondWithError(w, InvalidParameter(fmt.Errorf("container name is required")), r)
 		return
 	}
 
@@ -115,8 +141,7 @@ func handleContainerOperation(w http.ResponseWriter, r *http.Request, config *Co
 
 	switch req.Operation {
 	case "restart":
-		if !config.AllowRestart {
-			respondWithError(w, &AppError{Message: "Restart operation not allowed", Code: http.StatusForbidden}, r)
+		if !requireScope(w, r, "container", "restart", req.Container) {
 			return
 		}
 		if err := dockerClient.ContainerRestart(ctx, req.Container, container.StopOptions{}); err != nil {
@@ -124,50 +149,47 @@ func handleContainerOperation(w http.ResponseWriter, r *http.Request, config *Co
 				"container": req.Container,
 				"error":     err,
 			}).Error("Failed to restart container")
-			respondWithError(w, &AppError{Message: fmt.Sprintf("Failed to restart container: %v", err), Code: http.StatusInternalServerError}, r)
+			respondWithError(w, classifyDockerError(fmt.Errorf("failed to restart container: %w", err)), r)
 			return
 		}
 		logger.WithField("container", req.Container).Info("Container restarted")
 
 	case "stop":
-		if !config.AllowStop {
-			respondWithError(w, &AppError{Message: "Stop operation not allowed", Code: http.StatusForbidden}, r)
+		if !requireScope(w, r, "container", "stop", req.Container) {
 			return
 		}
 		if err := dockerClient.ContainerStop(ctx, req.Container, container.StopOptions{}); err != nil {
 			logger.Errorf("Failed to stop container %s: %v", req.Container, err)
-			respondWithError(w, &AppError{Message: fmt.Sprintf("Failed to stop container: %v", err), Code: http.StatusInternalServerError}, r)
+			respondWithError(w, classifyDockerError(fmt.Errorf("failed to stop container: %w", err)), r)
 			return
 		}
 		logger.Infof("Container %s stopped", req.Container)
 
 	case "start":
-		if !config.AllowStart {
-			respondWithError(w, &AppError{Message: "Start operation not allowed", Code: http.StatusForbidden}, r)
+		if !requireScope(w, r, "container", "start", req.Container) {
 			return
 		}
 		if err := dockerClient.ContainerStart(ctx, req.Container, container.StartOptions{}); err != nil {
 			logger.Errorf("Failed to start container %s: %v", req.Container, err)
-			respondWithError(w, &AppError{Message: fmt.Sprintf("Failed to start container: %v", err), Code: http.StatusInternalServerError}, r)
+			respondWithError(w, classifyDockerError(fmt.Errorf("failed to start container: %w", err)), r)
 			return
 		}
 		logger.Infof("Container %s started", req.Container)
 
 	case "remove":
-		if !config.AllowRemove {
-			respondWithError(w, &AppError{Message: "Remove operation not allowed", Code: http.StatusForbidden}, r)
+		if !requireScope(w, r, "container", "remove", req.Container) {
 			return
 		}
 		if err := dockerClient.ContainerRemove(ctx, req.Container, container.RemoveOptions{Force: true}); err != nil {
 			logger.Errorf("Failed to remove container %s: %v", req.Container, err)
-			respondWithError(w, &AppError{Message: fmt.Sprintf("Failed to remove container: %v", err), Code: http.StatusInternalServerError}, r)
+			respondWithError(w, classifyDockerError(fmt.Errorf("failed to remove container: %w", err)), r)
 			return
 		}
 
 		logger.Infof("Container %s removed", req.Container)
 
 	default:
-		respondWithError(w, &AppError{Message: "Invalid operation", Code: http.StatusBadRequest}, r)
+		respondWithError(w, InvalidParameter(fmt.Errorf("invalid operation: %s", req.Operation)), r)
 		return
 	}
 
@@ -188,29 +210,32 @@ func initLogger(logLevel string) (*logrus.Logger, error) {
 
 func loadConfig() (*Config, error) {
 	config := &Config{
-		AuthToken:          os.Getenv("AUTH_TOKEN"),
-		AllowRestart:       getEnvBool("ALLOW_RESTART", true),
-		AllowStop:          getEnvBool("ALLOW_STOP", true),
-		AllowStart:         getEnvBool("ALLOW_START", true),
-		AllowRemove:        getEnvBool("ALLOW_REMOVE", false),
-		AllowPull:          getEnvBool("ALLOW_PULL", true),
-		AllowComposeOps:    getEnvBool("ALLOW_COMPOSE", true),
-		Port:               getEnvInt("PORT", 8080),
-		LogLevel:           getEnvString("LOG_LEVEL", "info"),
-		ComposeProjectPath: getEnvString("COMPOSE_PATH", "./"),
+		TokensFile:          getEnvString("TOKENS_FILE", ""),
+		JWTHMACSecret:       os.Getenv("JWT_HMAC_SECRET"),
+		JWTRSAPublicKeyPath: getEnvString("JWT_RSA_PUBLIC_KEY", ""),
+		Port:                getEnvInt("PORT", 8080),
+		LogLevel:            getEnvString("LOG_LEVEL", "info"),
+		ComposeProjectPath:  getEnvString("COMPOSE_PATH", "./"),
+		MetricsListen:       getEnvString("METRICS_LISTEN", ""),
+		MetricsToken:        os.Getenv("METRICS_TOKEN"),
+		JobRetention:        time.Hour,
+		RegistryCredentials: getEnvString("REGISTRY_CREDENTIALS_FILE", ""),
+		DockerConfigPath:    getEnvString("DOCKER_CONFIG_FILE", ""),
 	}
 
 	// Define flags
-	pflag.StringVar(&config.AuthToken, "auth-token", config.AuthToken, "Auth token for API requests")
-	pflag.BoolVar(&config.AllowRestart, "allow-restart", config.AllowRestart, "Allow container restart operation")
-	pflag.BoolVar(&config.AllowStop, "allow-stop", config.AllowStop, "Allow container stop operation")
-	pflag.BoolVar(&config.AllowStart, "allow-start", config.AllowStart, "Allow container start operation")
-	pflag.BoolVar(&config.AllowRemove, "allow-remove", config.AllowRemove, "Allow container remove operation")
-	pflag.BoolVar(&config.AllowPull, "allow-pull", config.AllowPull, "Allow image pull operation")
-	pflag.BoolVar(&config.AllowComposeOps, "allow-compose", config.AllowComposeOps, "Allow Docker Compose operations")
+	pflag.StringVar(&config.TokensFile, "tokens-file", config.TokensFile, "Path to a YAML/JSON file of scoped token records")
+	pflag.StringVar(&config.JWTHMACSecret, "jwt-hmac-secret", config.JWTHMACSecret, "Shared secret for verifying HMAC-signed JWTs")
+	pflag.StringVar(&config.JWTRSAPublicKeyPath, "jwt-rsa-public-key", config.JWTRSAPublicKeyPath, "Path to a PEM-encoded RSA public key for verifying RSA-signed JWTs")
 	pflag.IntVar(&config.Port, "port", config.Port, "Port to listen on")
 	pflag.StringVar(&config.LogLevel, "log-level", config.LogLevel, "Log level (debug, info, warn, error)")
 	pflag.StringVar(&config.ComposeProjectPath, "compose-path", config.ComposeProjectPath, "Path to Docker Compose project")
+	pflag.StringVar(&config.MetricsListen, "metrics-listen", config.MetricsListen, "Address to serve /metrics on, separate from --port (e.g. :9090); defaults to the control API's own address")
+	pflag.StringVar(&config.MetricsToken, "metrics-token", config.MetricsToken, "Bearer token required to scrape /metrics when --metrics-allow-from is not set")
+	pflag.StringSliceVar(&config.MetricsAllowFrom, "metrics-allow-from", config.MetricsAllowFrom, "CIDRs allowed to scrape /metrics without a token")
+	pflag.DurationVar(&config.JobRetention, "job-retention", config.JobRetention, "How long completed async jobs are kept before being swept")
+	pflag.StringVar(&config.RegistryCredentials, "registry-credentials-file", config.RegistryCredentials, "Path to a YAML file of registry credentials, keyed by hostname")
+	pflag.StringVar(&config.DockerConfigPath, "docker-config", config.DockerConfigPath, "Path to a Docker config.json to fall back on for registry credentials (including credential helpers); defaults to the operator's own ~/.docker/config.json")
 	versionFlag := pflag.Bool("v", false, "Print the version and exit")
 	helpApi := pflag.Bool("help-api", false, "Show usage examples")
 
@@ -231,20 +256,10 @@ func loadConfig() (*Config, error) {
 		os.Exit(0)
 	}
 
-	// Generate a random auth token if not provided
-	if config.AuthToken == "" {
-		token, err := generateRandomToken(32)
-		if err != nil {
-			return nil, fmt.Errorf("failed to generate random auth token: %w", err)
-		}
-		fmt.Printf("Generated random auth token (WARNING: this will change each time you run the app!): %s\n", token)
-		config.AuthToken = token
+	if config.TokensFile == "" && config.JWTHMACSecret == "" && config.JWTRSAPublicKeyPath == "" {
+		fmt.Println("WARNING: no --tokens-file or JWT verification key configured, all requests will be accepted without authentication")
 	}
 
-	// Output the configured allowed operations
-	fmt.Printf("Allowed operations: restart=%t, stop=%t, start=%t, remove=%t, pull=%t, compose=%t\n",
-		config.AllowRestart, config.AllowStop, config.AllowStart, config.AllowRemove, config.AllowPull, config.AllowComposeOps)
-
 	return config, nil
 }
 func handleImageOperation(w http.ResponseWriter, r *http.Request, config *Config) {
@@ -254,7 +269,7 @@ func handleImageOperation(w http.ResponseWriter, r *http.Request, config *Config
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondWithError(w, &AppError{Message: "Invalid request body", Code: http.StatusBadRequest}, r)
+		respondWithError(w, InvalidParameter(fmt.Errorf("invalid request body: %w", err)), r)
 		return
 	}
 
@@ -262,14 +277,40 @@ func handleImageOperation(w http.ResponseWriter, r *http.Request, config *Config
 
 	switch req.Operation {
 	case "pull":
-		if !config.AllowPull {
-			respondWithError(w, &AppError{Message: "Pull operation not allowed", Code: http.StatusForbidden}, r)
+		if !requireScope(w, r, "image", "pull", req.Image) {
+			return
+		}
+
+		registryAuth, err := resolveRegistryAuth(r, req.Image, config)
+		if err != nil {
+			respondWithError(w, err, r)
+			return
+		}
+
+		if isAsyncRequest(r) {
+			jobID := jobIDFromRequest(r)
+			job, created := jobStore.getOrCreate(jobID, "image:pull", req.Image)
+			if !requireJobScope(w, r, job) {
+				return
+			}
+			if created {
+				jobCtx, cancel := context.WithCancel(context.Background())
+				job.setCancel(cancel)
+				go runImagePullJob(jobCtx, job, req.Image, registryAuth)
+			}
+			w.Header().Set("Location", fmt.Sprintf("/jobs/%s", job.ID))
+			respondWithJSON(w, http.StatusAccepted, job.snapshot())
 			return
 		}
-		reader, err := dockerClient.ImagePull(ctx, req.Image, image.PullOptions{})
+
+		reader, err := dockerClient.ImagePull(ctx, req.Image, image.PullOptions{RegistryAuth: registryAuth})
 		if err != nil {
-			logger.Errorf("Failed to pull image %s: %v", req.Image, err)
-			respondWithError(w, &AppError{Message: fmt.Sprintf("Failed to pull image: %v", err), Code: http.StatusInternalServerError}, r)
+			logger.WithFields(logrus.Fields{
+				"image":         req.Image,
+				"registry_auth": redactedRegistryAuthHeader(r),
+				"error":         err,
+			}).Error("Failed to pull image")
+			respondWithError(w, classifyDockerError(fmt.Errorf("failed to pull image: %w", err)), r)
 			return
 		}
 		defer reader.Close()
@@ -310,17 +351,14 @@ func handleImageOperation(w http.ResponseWriter, r *http.Request, config *Config
 		logger.Infof("Image %s pulled", req.Image)
 
 	default:
-		respondWithError(w, &AppError{Message: "Invalid operation", Code: http.StatusBadRequest}, r)
+		respondWithError(w, InvalidParameter(fmt.Errorf("invalid operation: %s", req.Operation)), r)
 		return
 	}
 }
 
+// handleComposeOperation runs a Compose operation against config.ComposeProjectPath
+// and streams build/pull progress back to the client as NDJSON
 func handleComposeOperation(w http.ResponseWriter, r *http.Request, config *Config) {
-	if !config.AllowComposeOps {
-		respondWithError(w, &AppError{Message: "Compose operations not allowed", Code: http.StatusForbidden}, r)
-		return
-	}
-
 	var req struct {
 		Operation string `json:"operation"`
 		Service   string `json:"service"`
@@ -328,69 +366,76 @@ func handleComposeOperation(w http.ResponseWriter, r *http.Request, config *Conf
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondWithError(w, &AppError{Message: "Invalid request body", Code: http.StatusBadRequest}, r)
+		respondWithError(w, InvalidParameter(fmt.Errorf("invalid request body: %w", err)), r)
 		return
 	}
 
-	ctx := r.Context()
-
 	switch req.Operation {
 	case "pull", "up", "down", "restart", "stop", "start":
-		if err := performComposeOperation(ctx, config.ComposeProjectPath, req.Operation, req.Service, req.Profile); err != nil {
-			logger.Errorf("Failed to perform %s operation on service %s: %v", req.Operation, req.Service, err)
-			respondWithError(w, &AppError{Message: fmt.Sprintf("Failed to perform operation: %v", err), Code: http.StatusInternalServerError}, r)
-			return
-		}
-		logger.Infof("Operation %s completed successfully on service %s", req.Operation, req.Service)
-
 	default:
-		respondWithError(w, &AppError{Message: "Invalid operation", Code: http.StatusBadRequest}, r)
+		respondWithError(w, InvalidParameter(fmt.Errorf("invalid operation: %s", req.Operation)), r)
 		return
 	}
 
-	respondWithMessage(w, http.StatusOK, fmt.Sprintf("Operation %s completed successfully on service %s", req.Operation, req.Service), r)
-}
-
-func performComposeOperation(ctx context.Context, projectPath, operation, service, profile string) error {
-	// The projectPath is a directory that could contain any number of docker*compose*.y*ml files, we simply need to set the current working directory to this path
-	if err := os.Chdir(projectPath); err != nil {
-		return fmt.Errorf("failed to change directory to %s: %w", projectPath, err)
+	target := req.Service
+	if target == "" {
+		target = "*"
 	}
-
-	args := []string{"compose"}
-
-	if profile != "" {
-		args = append(args, "--profile", profile)
+	if !requireScope(w, r, "compose", req.Operation, target) {
+		return
 	}
 
-	args = append(args, operation)
-
-	if service != "" {
-		args = append(args, service)
+	if (req.Operation == "up" || req.Operation == "pull") && isAsyncRequest(r) {
+		jobID := jobIDFromRequest(r)
+		job, created := jobStore.getOrCreate(jobID, "compose:"+req.Operation, target)
+		if !requireJobScope(w, r, job) {
+			return
+		}
+		if created {
+			jobCtx, cancel := context.WithCancel(context.Background())
+			job.setCancel(cancel)
+			go runComposeJob(jobCtx, job, config.ComposeProjectPath, req.Operation, req.Service, req.Profile)
+		}
+		w.Header().Set("Location", fmt.Sprintf("/jobs/%s", job.ID))
+		respondWithJSON(w, http.StatusAccepted, job.snapshot())
+		return
 	}
 
-	cmd := exec.CommandContext(ctx, "docker", args...)
-	output, err := cmd.CombinedOutput()
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher, canFlush := w.(http.Flusher)
 
-	if err != nil {
-		return fmt.Errorf("docker compose %s failed: %w\nOutput: %s", operation, err, string(output))
+	encoder := json.NewEncoder(w)
+	progressWriter := &ndjsonProgressWriter{
+		encode: func(v interface{}) { encoder.Encode(v) },
+		flush: func() {
+			if canFlush {
+				flusher.Flush()
+			}
+		},
 	}
 
-	logger.Infof("docker compose %s completed successfully for service %s", operation, service)
-	logger.Debugf("Command output: %s", string(output))
+	if err := composeSvc.Run(r.Context(), config.ComposeProjectPath, req.Operation, req.Service, req.Profile, progressWriter); err != nil {
+		logger.Errorf("Failed to perform %s operation on service %s: %v", req.Operation, req.Service, err)
+		encoder.Encode(map[string]string{"error": err.Error()})
+		return
+	}
 
-	return nil
+	logger.Infof("Operation %s completed successfully on service %s", req.Operation, req.Service)
+	encoder.Encode(map[string]string{"message": fmt.Sprintf("Operation %s completed successfully on service %s", req.Operation, req.Service)})
 }
 
-// respondWithError sends a JSON or pretty-printed error response
-func respondWithError(w http.ResponseWriter, err *AppError, r *http.Request) {
+// respondWithError sends a JSON or pretty-printed error response, deriving
+// the HTTP status code from err's typed errdefs category
+func respondWithError(w http.ResponseWriter, err error, r *http.Request) {
+	code := httpStatusFromError(err)
 	format := r.URL.Query().Get("format")
 	if format == "pretty" {
 		w.Header().Set("Content-Type", "text/plain")
-		w.WriteHeader(err.Code)
-		fmt.Fprintf(w, "Error: %s\n", err.Message)
+		w.WriteHeader(code)
+		fmt.Fprintf(w, "Error: %s\n", err.Error())
 	} else {
-		respondWithJSON(w, err.Code, map[string]string{"error": err.Message})
+		respondWithJSON(w, code, map[string]string{"error": err.Error()})
 	}
 }
 
@@ -414,31 +459,6 @@ func respondWithMessage(w http.ResponseWriter, code int, message string, r *http
 	}
 }
 
-// authMiddleware is a middleware function to handle authentication
-func authMiddleware(next http.HandlerFunc, config *Config) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		if config.AuthToken == "" {
-			next.ServeHTTP(w, r)
-			return
-		}
-
-		token := r.Header.Get("Authorization")
-		if token == "" {
-			respondWithError(w, &AppError{Message: "Missing authorization token", Code: http.StatusUnauthorized}, r)
-			logger.Warn("Missing authorization token from: ", r.RemoteAddr)
-			return
-		}
-
-		if token != fmt.Sprintf("Bearer %s", config.AuthToken) {
-			respondWithError(w, &AppError{Message: "Invalid authorization token", Code: http.StatusUnauthorized}, r)
-			logger.Warn("Invalid authorization token from: ", r.RemoteAddr)
-			return
-		}
-
-		next.ServeHTTP(w, r)
-	}
-}
-
 func getEnvBool(key string, defaultValue bool) bool {
 	if value, exists := os.LookupEnv(key); exists {
 		return value == "true"
@@ -462,14 +482,6 @@ func getEnvString(key, defaultValue string) string {
 	return defaultValue
 }
 
-func generateRandomToken(length int) (string, error) {
-	bytes := make([]byte, length)
-	if _, err := rand.Read(bytes); err != nil {
-		return "", err
-	}
-	return base64.URLEncoding.EncodeToString(bytes)[:length], nil
-}
-
 func printAPIUsageExamples(config *Config) {
 	fmt.Println("DockerAPI API Usage Examples:")
 	fmt.Println("-----------------------------------")
@@ -484,6 +496,36 @@ func printAPIUsageExamples(config *Config) {
 	printExample(config, "Docker Compose - Start a service", "/compose", `{"operation":"start","service":"web","profile":"development"}`)
 	printExample(config, "Docker Compose - Remove a service", "/compose", `{"operation":"remove","service":"web","profile":"development"}`)
 	printExample(config, "Docker Compose - Pull images for a service", "/compose", `{"operation":"pull","service":"web","profile":"development"}`)
+
+	fmt.Println("\nStream a container's logs (follow, tail, SSE/NDJSON/raw via ?format=):")
+	fmt.Println(" curl -H \"Authorization: Bearer <token>\" -H \"X-Key-Id: <kid>\" \\")
+	fmt.Printf("  \"http://localhost:%d/container/logs?container=my-container&follow=true&tail=100&format=sse\"\n", config.Port)
+
+	fmt.Println("\nWatch the Docker event bus (SSE, optionally filtered):")
+	fmt.Println(" curl -H \"Authorization: Bearer <token>\" -H \"X-Key-Id: <kid>\" \\")
+	fmt.Printf("  \"http://localhost:%d/events?type=container&container=^my-.*\"\n", config.Port)
+
+	fmt.Println("\nStream a single container's stats (SSE):")
+	fmt.Println(" curl -H \"Authorization: Bearer <token>\" -H \"X-Key-Id: <kid>\" \\")
+	fmt.Printf("  \"http://localhost:%d/container/stats?container=my-container&stream=true\"\n", config.Port)
+
+	fmt.Println("\nScrape Prometheus metrics (served on --metrics-listen if set, otherwise alongside the control API):")
+	fmt.Printf(" curl http://localhost:%d/metrics\n", config.Port)
+
+	fmt.Println("\nRun a long operation asynchronously and poll for its result:")
+	fmt.Println(" curl -H \"Authorization: Bearer <token>\" -H \"X-Key-Id: <kid>\" -H \"Prefer: respond-async\" \\")
+	fmt.Println("  -H \"Idempotency-Key: <unique-key>\" -d '{\"operation\":\"pull\",\"image\":\"nginx:latest\"}' \\")
+	fmt.Printf("  \"http://localhost:%d/image\"\n", config.Port)
+	fmt.Println(" curl -H \"Authorization: Bearer <token>\" -H \"X-Key-Id: <kid>\" \\")
+	fmt.Printf("  \"http://localhost:%d/jobs/<job-id>\"\n", config.Port)
+	fmt.Println(" curl -H \"Authorization: Bearer <token>\" -H \"X-Key-Id: <kid>\" \\")
+	fmt.Printf("  \"http://localhost:%d/jobs/<job-id>/logs\"\n", config.Port)
+
+	fmt.Println("\nPull an image from a private registry (credentials also resolved from --registry-credentials-file or --docker-config if the header is omitted):")
+	fmt.Println(" curl -X POST -H \"Authorization: Bearer <token>\" -H \"X-Key-Id: <kid>\" \\")
+	fmt.Println("  -H \"X-Registry-Auth: $(echo -n '{\"username\":\"user\",\"password\":\"pass\",\"serveraddress\":\"ghcr.io\"}' | base64 -w0)\" \\")
+	fmt.Println("  -d '{\"operation\":\"pull\",\"image\":\"ghcr.io/example/private-image:latest\"}' \\")
+	fmt.Printf("  \"http://localhost:%d/image\"\n", config.Port)
 }
 
 func colouriseJSON(jsonString string) string {
@@ -539,7 +581,7 @@ func colouriseJSON(jsonString string) string {
 
 func printExample(config *Config, description, endpoint, jsonData string) {
 	fmt.Printf("\n%s:\n", description)
-	fmt.Printf("curl -X POST -H \"Content-Type: application/json\" -H \"Authorization: Bearer %s\" \\\n", config.AuthToken)
+	fmt.Println("curl -X POST -H \"Content-Type: application/json\" -H \"Authorization: Bearer <token>\" -H \"X-Key-Id: <kid>\" \\")
 	fmt.Printf(" -d '\n%s\n' \\\n", colouriseJSON(jsonData))
 	fmt.Printf(" http://localhost:%d%s\n", config.Port, endpoint)
 	fmt.Printf("\nFor pretty-printed output, add ?format=pretty to the URL:\n")
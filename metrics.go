@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const metricsCollectionInterval = 15 * time.Second
+
+var (
+	containerCPUPercent = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "dockerapi",
+		Subsystem: "container",
+		Name:      "cpu_percent",
+		Help:      "Container CPU usage as a percentage of a single core",
+	}, []string{"name", "image", "compose_project", "compose_service"})
+
+	containerMemUsageBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "dockerapi",
+		Subsystem: "container",
+		Name:      "memory_usage_bytes",
+		Help:      "Container memory usage in bytes",
+	}, []string{"name", "image", "compose_project", "compose_service"})
+
+	containerMemLimitBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "dockerapi",
+		Subsystem: "container",
+		Name:      "memory_limit_bytes",
+		Help:      "Container memory limit in bytes",
+	}, []string{"name", "image", "compose_project", "compose_service"})
+
+	containerNetRxBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "dockerapi",
+		Subsystem: "container",
+		Name:      "network_rx_bytes",
+		Help:      "Total bytes received across all of the container's network interfaces",
+	}, []string{"name", "image", "compose_project", "compose_service"})
+
+	containerNetTxBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "dockerapi",
+		Subsystem: "container",
+		Name:      "network_tx_bytes",
+		Help:      "Total bytes sent across all of the container's network interfaces",
+	}, []string{"name", "image", "compose_project", "compose_service"})
+
+	containerBlkioReadBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "dockerapi",
+		Subsystem: "container",
+		Name:      "blkio_read_bytes",
+		Help:      "Total bytes read from block devices",
+	}, []string{"name", "image", "compose_project", "compose_service"})
+
+	containerBlkioWriteBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "dockerapi",
+		Subsystem: "container",
+		Name:      "blkio_write_bytes",
+		Help:      "Total bytes written to block devices",
+	}, []string{"name", "image", "compose_project", "compose_service"})
+
+	containerRestartCount = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "dockerapi",
+		Subsystem: "container",
+		Name:      "restart_count",
+		Help:      "Number of times the container has been restarted by the Docker daemon",
+	}, []string{"name", "image", "compose_project", "compose_service"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		containerCPUPercent,
+		containerMemUsageBytes,
+		containerMemLimitBytes,
+		containerNetRxBytes,
+		containerNetTxBytes,
+		containerBlkioReadBytes,
+		containerBlkioWriteBytes,
+		containerRestartCount,
+	)
+}
+
+// startMetricsCollector periodically enumerates running containers and
+// refreshes the Prometheus gauges above from their stats, until ctx is
+// cancelled.
+func startMetricsCollector(ctx context.Context) {
+	ticker := time.NewTicker(metricsCollectionInterval)
+	defer ticker.Stop()
+
+	for {
+		collectContainerMetrics(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func collectContainerMetrics(ctx context.Context) {
+	containers, err := dockerClient.ContainerList(ctx, container.ListOptions{})
+	if err != nil {
+		logger.Errorf("metrics: failed to list containers: %v", err)
+		return
+	}
+
+	for _, c := range containers {
+		name := c.ID
+		if len(c.Names) > 0 {
+			name = strings.TrimPrefix(c.Names[0], "/")
+		}
+
+		labels := prometheus.Labels{
+			"name":            name,
+			"image":           c.Image,
+			"compose_project": c.Labels["com.docker.compose.project"],
+			"compose_service": c.Labels["com.docker.compose.service"],
+		}
+
+		stats, err := dockerClient.ContainerStatsOneShot(ctx, c.ID)
+		if err != nil {
+			logger.Debugf("metrics: failed to fetch stats for %s: %v", name, err)
+			continue
+		}
+
+		var v container.StatsResponse
+		decodeErr := json.NewDecoder(stats.Body).Decode(&v)
+		stats.Body.Close()
+		if decodeErr != nil {
+			logger.Debugf("metrics: failed to decode stats for %s: %v", name, decodeErr)
+			continue
+		}
+
+		containerCPUPercent.With(labels).Set(cpuPercentFromStats(&v))
+		containerMemUsageBytes.With(labels).Set(float64(v.MemoryStats.Usage))
+		containerMemLimitBytes.With(labels).Set(float64(v.MemoryStats.Limit))
+
+		var rx, tx uint64
+		for _, n := range v.Networks {
+			rx += n.RxBytes
+			tx += n.TxBytes
+		}
+		containerNetRxBytes.With(labels).Set(float64(rx))
+		containerNetTxBytes.With(labels).Set(float64(tx))
+
+		var blkRead, blkWrite uint64
+		for _, entry := range v.BlkioStats.IoServiceBytesRecursive {
+			switch strings.ToLower(entry.Op) {
+			case "read":
+				blkRead += entry.Value
+			case "write":
+				blkWrite += entry.Value
+			}
+		}
+		containerBlkioReadBytes.With(labels).Set(float64(blkRead))
+		containerBlkioWriteBytes.With(labels).Set(float64(blkWrite))
+
+		if inspect, err := dockerClient.ContainerInspect(ctx, c.ID); err == nil {
+			containerRestartCount.With(labels).Set(float64(inspect.RestartCount))
+		}
+	}
+}
+
+// cpuPercentFromStats mirrors the CPU percentage calculation `docker
+// stats` itself uses: the container's share of the CPU delta since the
+// previous sample, scaled by the number of online CPUs.
+func cpuPercentFromStats(v *container.StatsResponse) float64 {
+	cpuDelta := float64(v.CPUStats.CPUUsage.TotalUsage) - float64(v.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(v.CPUStats.SystemUsage) - float64(v.PreCPUStats.SystemUsage)
+	if cpuDelta <= 0 || systemDelta <= 0 {
+		return 0
+	}
+
+	onlineCPUs := float64(v.CPUStats.OnlineCPUs)
+	if onlineCPUs == 0 {
+		onlineCPUs = float64(len(v.CPUStats.CPUUsage.PercpuUsage))
+	}
+	if onlineCPUs == 0 {
+		onlineCPUs = 1
+	}
+
+	return (cpuDelta / systemDelta) * onlineCPUs * 100.0
+}
+
+// metricsAuthMiddleware gates /metrics separately from the control API:
+// either the caller's address must fall within config.MetricsAllowFrom,
+// or (if no allowlist is configured) it must present config.MetricsToken.
+func metricsAuthMiddleware(next http.Handler, config *Config) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(config.MetricsAllowFrom) > 0 {
+			if remoteAddrAllowed(r.RemoteAddr, config.MetricsAllowFrom) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			respondWithError(w, Forbidden(fmt.Errorf("remote address not permitted to scrape metrics")), r)
+			return
+		}
+
+		if config.MetricsToken != "" {
+			if r.Header.Get("Authorization") != fmt.Sprintf("Bearer %s", config.MetricsToken) {
+				respondWithError(w, Unauthorized(fmt.Errorf("invalid metrics token")), r)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func remoteAddrAllowed(remoteAddr string, allowed []string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	for _, cidr := range allowed {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			continue
+		}
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// startMetricsServer exposes /metrics, either on its own listener
+// (config.MetricsListen) so it can be bound to a different port/interface
+// than the control API, or on the main mux if no separate address was
+// configured.
+func startMetricsServer(config *Config) {
+	handler := metricsAuthMiddleware(promhttp.Handler(), config)
+
+	if config.MetricsListen == "" {
+		http.Handle("/metrics", handler)
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", handler)
+
+	go func() {
+		logger.Infof("Starting metrics server on %s", config.MetricsListen)
+		if err := http.ListenAndServe(config.MetricsListen, mux); err != nil {
+			logger.Errorf("Metrics server stopped: %v", err)
+		}
+	}()
+}
+
+// handleContainerStats streams a single container's resource usage stats
+// as SSE, driven by dockerClient.ContainerStats.
+func handleContainerStats(w http.ResponseWriter, r *http.Request, config *Config) {
+	containerName := r.URL.Query().Get("container")
+	if containerName == "" {
+		respondWithError(w, InvalidParameter(fmt.Errorf("container name is required")), r)
+		return
+	}
+
+	if !requireScope(w, r, "container", "stats", containerName) {
+		return
+	}
+
+	stream, _ := strconv.ParseBool(r.URL.Query().Get("stream"))
+
+	ctx := r.Context()
+	statsResp, err := dockerClient.ContainerStats(ctx, containerName, stream)
+	if err != nil {
+		respondWithError(w, classifyDockerError(fmt.Errorf("failed to fetch container stats: %w", err)), r)
+		return
+	}
+	defer statsResp.Body.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher, canFlush := w.(http.Flusher)
+
+	decoder := json.NewDecoder(statsResp.Body)
+	for {
+		var v container.StatsResponse
+		if err := decoder.Decode(&v); err != nil {
+			if err != io.EOF {
+				logger.Debugf("container stats stream ended: %v", err)
+			}
+			return
+		}
+
+		payload, err := json.Marshal(v)
+		if err != nil {
+			continue
+		}
+
+		fmt.Fprintf(w, "data: %s\n\n", payload)
+		if canFlush {
+			flusher.Flush()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+	}
+}
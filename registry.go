@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	dockercliconfig "github.com/docker/cli/cli/config"
+	clitypes "github.com/docker/cli/cli/config/types"
+	"github.com/docker/docker/api/types/registry"
+	"gopkg.in/yaml.v3"
+)
+
+const defaultRegistryHost = "docker.io"
+
+// registryCredential is one entry of the server-side credential store,
+// letting trusted callers pull from a private registry (ghcr.io, ECR, a
+// private Harbor, ...) without ever sending its secret in the request.
+type registryCredential struct {
+	Host     string `yaml:"host"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// registryCredentialStore resolves pull credentials by registry hostname.
+type registryCredentialStore struct {
+	byHost map[string]registryCredential
+}
+
+// loadRegistryCredentialStore reads a YAML file of registryCredential
+// entries. It returns (nil, nil) if path is empty, signalling that no
+// server-side credentials are configured.
+func loadRegistryCredentialStore(path string) (*registryCredentialStore, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read registry credentials file %s: %w", path, err)
+	}
+
+	var creds []registryCredential
+	if err := yaml.Unmarshal(data, &creds); err != nil {
+		return nil, fmt.Errorf("failed to parse registry credentials file %s: %w", path, err)
+	}
+
+	store := &registryCredentialStore{byHost: map[string]registryCredential{}}
+	for _, c := range creds {
+		if c.Host == "" {
+			return nil, fmt.Errorf("registry credential in %s is missing a host", path)
+		}
+		store.byHost[c.Host] = c
+	}
+
+	return store, nil
+}
+
+func (s *registryCredentialStore) lookup(host string) (registryCredential, bool) {
+	if s == nil {
+		return registryCredential{}, false
+	}
+	cred, ok := s.byHost[host]
+	return cred, ok
+}
+
+// registryHostname extracts the registry hostname a reference will be
+// pulled from, mirroring Docker's own reference-parsing rules: the part
+// before the first "/" only counts as a hostname if it looks like one
+// (contains a "." or ":", or is "localhost"); otherwise the image is
+// assumed to live on the default registry.
+func registryHostname(imageRef string) string {
+	name := imageRef
+	if at := strings.IndexByte(name, '@'); at != -1 {
+		name = name[:at]
+	}
+
+	slash := strings.IndexByte(name, '/')
+	if slash == -1 {
+		return defaultRegistryHost
+	}
+
+	candidate := name[:slash]
+	if candidate == "localhost" || strings.ContainsAny(candidate, ".:") {
+		return candidate
+	}
+
+	return defaultRegistryHost
+}
+
+// encodeRegistryAuth base64-encodes auth the way the Docker daemon expects
+// it on the X-Registry-Auth header / RegistryAuth pull option.
+func encodeRegistryAuth(auth registry.AuthConfig) (string, error) {
+	payload, err := json.Marshal(auth)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode registry auth: %w", err)
+	}
+	return base64.URLEncoding.EncodeToString(payload), nil
+}
+
+// redactedRegistryAuthHeader returns a value safe to pass to the logger in
+// place of the request's X-Registry-Auth header, which carries a
+// credential and must never appear in logs verbatim.
+func redactedRegistryAuthHeader(r *http.Request) string {
+	if r.Header.Get("X-Registry-Auth") == "" {
+		return ""
+	}
+	return "[redacted]"
+}
+
+// decodeRegistryAuthHeader parses the client-supplied X-Registry-Auth
+// header. Errors deliberately omit the header value itself, since it may
+// carry a credential.
+func decodeRegistryAuthHeader(header string) (string, error) {
+	decoded, err := base64.URLEncoding.DecodeString(header)
+	if err != nil {
+		decoded, err = base64.StdEncoding.DecodeString(header)
+		if err != nil {
+			return "", InvalidParameter(fmt.Errorf("X-Registry-Auth header is not valid base64"))
+		}
+	}
+
+	var auth registry.AuthConfig
+	if err := json.Unmarshal(decoded, &auth); err != nil {
+		return "", InvalidParameter(fmt.Errorf("X-Registry-Auth header is not a valid registry auth payload"))
+	}
+
+	return encodeRegistryAuth(auth)
+}
+
+// registryAuthFromDockerConfig resolves credentials for host from a Docker
+// config.json, including invoking any configured credential helper, the
+// same way the `docker` CLI itself would.
+func registryAuthFromDockerConfig(host, configPath string) (string, error) {
+	var authConfig clitypes.AuthConfig
+	var err error
+
+	// The docker CLI stores Docker Hub credentials under its index server
+	// address rather than under the "docker.io" hostname.
+	lookupHost := host
+	if host == defaultRegistryHost {
+		lookupHost = "https://index.docker.io/v1/"
+	}
+
+	if configPath != "" {
+		f, openErr := os.Open(configPath)
+		if openErr != nil {
+			if os.IsNotExist(openErr) {
+				return "", nil
+			}
+			return "", fmt.Errorf("failed to open docker config %s: %w", configPath, openErr)
+		}
+		defer f.Close()
+
+		parsed, loadErr := dockercliconfig.LoadFromReader(f)
+		if loadErr != nil {
+			return "", fmt.Errorf("failed to parse docker config %s: %w", configPath, loadErr)
+		}
+		authConfig, err = parsed.GetAuthConfig(lookupHost)
+	} else {
+		authConfig, err = dockercliconfig.LoadDefaultConfigFile(io.Discard).GetAuthConfig(lookupHost)
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve docker config credentials for %s: %w", host, err)
+	}
+
+	if authConfig.Username == "" && authConfig.Password == "" && authConfig.IdentityToken == "" {
+		return "", nil
+	}
+
+	return encodeRegistryAuth(registry.AuthConfig{
+		Username:      authConfig.Username,
+		Password:      authConfig.Password,
+		Auth:          authConfig.Auth,
+		ServerAddress: authConfig.ServerAddress,
+		IdentityToken: authConfig.IdentityToken,
+		RegistryToken: authConfig.RegistryToken,
+	})
+}
+
+// resolveRegistryAuth determines the base64-encoded RegistryAuth to present
+// to the Docker daemon for imageName, preferring, in order: an
+// X-Registry-Auth header on the request, a matching entry in the
+// server-side credential store, then the operator's Docker config.json
+// (and any credential helper it configures). An empty return means the
+// pull will be attempted anonymously.
+func resolveRegistryAuth(r *http.Request, imageName string, config *Config) (string, error) {
+	if header := r.Header.Get("X-Registry-Auth"); header != "" {
+		return decodeRegistryAuthHeader(header)
+	}
+
+	host := registryHostname(imageName)
+
+	if cred, ok := registryCredStore.lookup(host); ok {
+		return encodeRegistryAuth(registry.AuthConfig{
+			Username:      cred.Username,
+			Password:      cred.Password,
+			ServerAddress: host,
+		})
+	}
+
+	return registryAuthFromDockerConfig(host, config.DockerConfigPath)
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/sirupsen/logrus"
+)
+
+// streamFormat selects how log/event output is framed on the wire
+type streamFormat int
+
+const (
+	formatRaw streamFormat = iota
+	formatNDJSON
+	formatSSE
+)
+
+// negotiateStreamFormat picks a streamFormat from the "format" query param,
+// falling back to the Accept header and finally raw text
+func negotiateStreamFormat(r *http.Request) streamFormat {
+	switch r.URL.Query().Get("format") {
+	case "ndjson":
+		return formatNDJSON
+	case "sse":
+		return formatSSE
+	case "raw":
+		return formatRaw
+	}
+
+	accept := r.Header.Get("Accept")
+	switch {
+	case accept == "text/event-stream":
+		return formatSSE
+	case accept == "application/x-ndjson":
+		return formatNDJSON
+	default:
+		return formatRaw
+	}
+}
+
+// handleContainerLogs streams a container's stdout/stderr to the client,
+// demuxing the Docker multiplexed log stream with stdcopy
+func handleContainerLogs(w http.ResponseWriter, r *http.Request, config *Config) {
+	containerName := r.URL.Query().Get("container")
+	if containerName == "" {
+		respondWithError(w, InvalidParameter(fmt.Errorf("container name is required")), r)
+		return
+	}
+
+	if !requireScope(w, r, "container", "logs", containerName) {
+		return
+	}
+
+	follow, _ := strconv.ParseBool(r.URL.Query().Get("follow"))
+	timestamps, _ := strconv.ParseBool(r.URL.Query().Get("timestamps"))
+
+	options := container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     follow,
+		Timestamps: timestamps,
+		Tail:       r.URL.Query().Get("tail"),
+		Since:      r.URL.Query().Get("since"),
+	}
+
+	ctx := r.Context()
+
+	reader, err := dockerClient.ContainerLogs(ctx, containerName, options)
+	if err != nil {
+		logger.WithFields(logrus.Fields{
+			"container": containerName,
+			"error":     err,
+		}).Error("Failed to fetch container logs")
+		respondWithError(w, classifyDockerError(fmt.Errorf("failed to fetch container logs: %w", err)), r)
+		return
+	}
+	defer reader.Close()
+
+	format := negotiateStreamFormat(r)
+
+	flusher, canFlush := w.(http.Flusher)
+
+	switch format {
+	case formatSSE:
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+	case formatNDJSON:
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	default:
+		w.Header().Set("Content-Type", "text/plain")
+	}
+	w.WriteHeader(http.StatusOK)
+	if canFlush {
+		flusher.Flush()
+	}
+
+	writeLine := func(stream string, line string) {
+		switch format {
+		case formatSSE:
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", stream, line)
+		case formatNDJSON:
+			payload, _ := json.Marshal(map[string]string{"stream": stream, "line": line})
+			w.Write(payload)
+			w.Write([]byte("\n"))
+		default:
+			fmt.Fprintf(w, "%s\n", line)
+		}
+		if canFlush {
+			flusher.Flush()
+		}
+	}
+
+	stdout := &lineWriter{write: func(line string) { writeLine("stdout", line) }}
+	stderr := &lineWriter{write: func(line string) { writeLine("stderr", line) }}
+
+	if _, err := stdcopy.StdCopy(stdout, stderr, reader); err != nil {
+		logger.WithField("container", containerName).Debugf("log stream ended: %v", err)
+	}
+}
+
+// lineWriter buffers partial writes from stdcopy.StdCopy and emits one
+// callback per line, since the demuxed stream is not guaranteed to be
+// newline-aligned on each Write call
+type lineWriter struct {
+	buf   []byte
+	write func(line string)
+}
+
+func (lw *lineWriter) Write(p []byte) (int, error) {
+	lw.buf = append(lw.buf, p...)
+	for {
+		idx := -1
+		for i, b := range lw.buf {
+			if b == '\n' {
+				idx = i
+				break
+			}
+		}
+		if idx == -1 {
+			break
+		}
+		lw.write(string(lw.buf[:idx]))
+		lw.buf = lw.buf[idx+1:]
+	}
+	return len(p), nil
+}
+
+// handleEvents streams the Docker daemon's event stream as SSE, optionally
+// filtered by label, type, or container name regex
+func handleEvents(w http.ResponseWriter, r *http.Request, config *Config) {
+	if !requireScope(w, r, "events", "read", "*") {
+		return
+	}
+
+	query := r.URL.Query()
+
+	eventFilters := filters.NewArgs()
+	for _, label := range query["label"] {
+		eventFilters.Add("label", label)
+	}
+	for _, typ := range query["type"] {
+		eventFilters.Add("type", typ)
+	}
+
+	var nameRegex *regexp.Regexp
+	if pattern := query.Get("container"); pattern != "" {
+		var err error
+		nameRegex, err = regexp.Compile(pattern)
+		if err != nil {
+			respondWithError(w, InvalidParameter(fmt.Errorf("invalid container filter regex: %w", err)), r)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	messages, errs := dockerClient.Events(ctx, events.ListOptions{Filters: eventFilters})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	flusher, canFlush := w.(http.Flusher)
+	if canFlush {
+		flusher.Flush()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case err := <-errs:
+			if err != nil {
+				logger.Debugf("events stream ended: %v", err)
+			}
+			return
+		case msg := <-messages:
+			if nameRegex != nil && !nameRegex.MatchString(msg.Actor.Attributes["name"]) {
+				continue
+			}
+			payload, err := json.Marshal(msg)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", msg.Type, payload)
+			if canFlush {
+				flusher.Flush()
+			}
+		case <-time.After(30 * time.Second):
+			// heartbeat comment to keep intermediaries from closing the connection
+			fmt.Fprint(w, ": keep-alive\n\n")
+			if canFlush {
+				flusher.Flush()
+			}
+		}
+	}
+}